@@ -0,0 +1,72 @@
+package sqalx
+
+import (
+	"context"
+	"time"
+)
+
+// Hooks lets callers observe the transaction and savepoint lifecycle of a
+// Node, e.g. to emit tracing spans or metrics. txID identifies the
+// transaction or savepoint the event concerns: it is assigned once, when
+// that transaction or savepoint is opened, and stays the same across every
+// hook call for it (Begin, Commit or Rollback, and Savepoint/Release/
+// RollbackTo when applicable) regardless of nesting depth or context, so it
+// is safe to use as a map key even when concurrent siblings share a context.
+// depth is the nesting depth of the Node the hook fires on (0 for the
+// top-level Node returned by New). savepointID is the savepoint identifier
+// when the event concerns a savepoint, and empty otherwise. The "After"
+// variants additionally receive how long the underlying operation took and
+// the error it returned, if any.
+//
+// Register hooks with the WithHooks option. All callbacks are optional: embed
+// NoopHooks to only implement the ones you need.
+type Hooks interface {
+	// BeforeBegin fires before a new transaction or savepoint is opened, or
+	// before an existing transaction is reused by a nested Beginx call.
+	BeforeBegin(ctx context.Context, txID string, depth int, savepointID string)
+	// AfterBegin fires after BeforeBegin's operation completes.
+	AfterBegin(ctx context.Context, txID string, depth int, savepointID string, elapsed time.Duration, err error)
+	// BeforeCommit fires before Commit does anything.
+	BeforeCommit(ctx context.Context, txID string, depth int, savepointID string)
+	// AfterCommit fires after Commit's operation completes.
+	AfterCommit(ctx context.Context, txID string, depth int, savepointID string, elapsed time.Duration, err error)
+	// BeforeRollback fires before Rollback does anything.
+	BeforeRollback(ctx context.Context, txID string, depth int, savepointID string)
+	// AfterRollback fires after Rollback's operation completes.
+	AfterRollback(ctx context.Context, txID string, depth int, savepointID string, elapsed time.Duration, err error)
+	// BeforeSavepoint fires immediately before the SAVEPOINT statement for a
+	// nested transaction is executed.
+	BeforeSavepoint(ctx context.Context, txID string, depth int, savepointID string)
+	// AfterSavepoint fires after the SAVEPOINT statement completes.
+	AfterSavepoint(ctx context.Context, txID string, depth int, savepointID string, elapsed time.Duration, err error)
+	// BeforeReleaseSavepoint fires immediately before the RELEASE SAVEPOINT
+	// statement is executed, as part of Commit.
+	BeforeReleaseSavepoint(ctx context.Context, txID string, depth int, savepointID string)
+	// BeforeRollbackToSavepoint fires immediately before the ROLLBACK TO
+	// SAVEPOINT statement is executed, as part of Rollback.
+	BeforeRollbackToSavepoint(ctx context.Context, txID string, depth int, savepointID string)
+}
+
+// NoopHooks implements Hooks with empty methods. Embed it to implement only
+// the callbacks you care about.
+type NoopHooks struct{}
+
+func (NoopHooks) BeforeBegin(context.Context, string, int, string)                          {}
+func (NoopHooks) AfterBegin(context.Context, string, int, string, time.Duration, error)     {}
+func (NoopHooks) BeforeCommit(context.Context, string, int, string)                         {}
+func (NoopHooks) AfterCommit(context.Context, string, int, string, time.Duration, error)    {}
+func (NoopHooks) BeforeRollback(context.Context, string, int, string)                       {}
+func (NoopHooks) AfterRollback(context.Context, string, int, string, time.Duration, error)  {}
+func (NoopHooks) BeforeSavepoint(context.Context, string, int, string)                      {}
+func (NoopHooks) AfterSavepoint(context.Context, string, int, string, time.Duration, error) {}
+func (NoopHooks) BeforeReleaseSavepoint(context.Context, string, int, string)               {}
+func (NoopHooks) BeforeRollbackToSavepoint(context.Context, string, int, string)            {}
+
+// WithHooks option registers h to observe every Beginx, Commit and Rollback
+// on the Node (and on every Node nested from it).
+func WithHooks(h Hooks) Option {
+	return func(n *node) error {
+		n.hooks = h
+		return nil
+	}
+}
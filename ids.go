@@ -0,0 +1,34 @@
+package sqalx
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"sync/atomic"
+)
+
+// idSeq and idInstance together produce identifiers that are monotonic
+// within a process and collision-free across processes, without depending on
+// a UUID library. They back both savepoint identifiers and the opaque
+// transaction identifiers handed to Hooks.
+var (
+	idSeq      uint64
+	idInstance = newIDInstance()
+)
+
+func newIDInstance() string {
+	var b [8]byte
+	// crypto/rand.Read on the package-level Reader only fails if the
+	// underlying OS source is unavailable, which would itself be a fatal
+	// environment problem; falling back to an all-zero instance ID still
+	// keeps IDs unique within this process via the sequence counter.
+	rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// nextID returns a new identifier prefixed with prefix, unique within this
+// process and (bar the crypto/rand fallback above) across processes.
+func nextID(prefix string) string {
+	seq := atomic.AddUint64(&idSeq, 1)
+	return fmt.Sprintf("%s_%s_%d", prefix, idInstance, seq)
+}
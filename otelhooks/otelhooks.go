@@ -0,0 +1,199 @@
+// Package otelhooks implements sqalx.Hooks on top of OpenTelemetry tracing,
+// emitting one span per transaction/savepoint opened through sqalx.
+package otelhooks
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/gbl08ma/sqalx"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// transaction and savepoint are the names given to the spans started for a
+// new top-level transaction and for a SAVEPOINT, respectively.
+const (
+	transaction = "db.sqalx.transaction"
+	savepoint   = "db.sqalx.savepoint"
+)
+
+// Hooks implements sqalx.Hooks, starting an OpenTelemetry span for every
+// top-level transaction and every savepoint, and recording commit/rollback
+// errors on it. Embedding sqalx.NoopHooks is not necessary: Hooks implements
+// every method of sqalx.Hooks.
+//
+// A zero Hooks uses otel.Tracer("") as its tracer; use New to name it.
+type Hooks struct {
+	tracer trace.Tracer
+
+	mu sync.Mutex
+	// spans is keyed by the txID sqalx assigns to each transaction and
+	// savepoint, not by (ctx, depth): sqalx hands a stable txID to every hook
+	// call for a given transaction or savepoint, from BeforeBegin through to
+	// its matching Commit/Rollback, so it is safe to use as a map key even
+	// when concurrent sibling transactions share a context or nesting depth.
+	//
+	// A nested Beginx that merely reuses its parent's transaction (no
+	// savepoint) is given its parent's txID too, so a Commit/Rollback on it
+	// carries the same key as the parent's own span. endDepth -- the depth a
+	// Node has once the span-owning Begin call returns -- is what tells the
+	// two apart: only the Commit/Rollback that fires at that exact depth is
+	// the one that actually owns the span.
+	spans map[string]spanEntry
+}
+
+type spanEntry struct {
+	span     trace.Span
+	endDepth int
+}
+
+// New creates Hooks that start spans on tracer.
+func New(tracer trace.Tracer) *Hooks {
+	return &Hooks{
+		tracer: tracer,
+		spans:  make(map[string]spanEntry),
+	}
+}
+
+func (h *Hooks) tracerOrDefault() trace.Tracer {
+	if h.tracer != nil {
+		return h.tracer
+	}
+	return otel.Tracer("")
+}
+
+func (h *Hooks) startSpan(ctx context.Context, txID, name string, endDepth int) {
+	_, span := h.tracerOrDefault().Start(ctx, name)
+
+	h.mu.Lock()
+	if h.spans == nil {
+		h.spans = make(map[string]spanEntry)
+	}
+	h.spans[txID] = spanEntry{span: span, endDepth: endDepth}
+	h.mu.Unlock()
+}
+
+func (h *Hooks) span(txID string) (trace.Span, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	entry, ok := h.spans[txID]
+	return entry.span, ok
+}
+
+// ownsSpan reports whether a node at depth/savepointID is one BeforeBegin
+// starts a span for: a new top-level transaction (depth 0) or a new
+// savepoint. A nested Beginx that reuses its parent's transaction (depth != 0,
+// no savepointID) never gets a span of its own. A Beginx on a depth-0 Node
+// created by sqalx.NewFromTransaction also reuses a transaction, but as it
+// fires at depth 0 it is treated as a top-level transaction and gets a span.
+func ownsSpan(depth int, savepointID string) bool {
+	return depth == 0 || savepointID != ""
+}
+
+// endSpan ends the span stored under txID, but only if depth is the depth its
+// owning Begin call left its Node at: a reuse node shares its parent's txID
+// and fires its own Commit/Rollback hooks at a deeper depth, so this leaves
+// the parent's still-open span alone instead of ending (or mis-recording an
+// error on) it.
+func (h *Hooks) endSpan(txID string, depth int, err error) {
+	h.mu.Lock()
+	entry, ok := h.spans[txID]
+	if ok && entry.endDepth == depth {
+		delete(h.spans, txID)
+	}
+	h.mu.Unlock()
+
+	if !ok || entry.endDepth != depth {
+		return
+	}
+
+	if err != nil {
+		entry.span.RecordError(err)
+		entry.span.SetStatus(codes.Error, err.Error())
+	}
+	entry.span.End()
+}
+
+// BeforeBegin starts a span for a new top-level transaction (depth 0) or a
+// new savepoint. Nested transactions that reuse their parent's transaction
+// without a savepoint get no span of their own.
+func (h *Hooks) BeforeBegin(ctx context.Context, txID string, depth int, savepointID string) {
+	if !ownsSpan(depth, savepointID) {
+		return
+	}
+
+	name := transaction
+	if savepointID != "" {
+		name = savepoint
+	}
+
+	// the Node BeforeBegin is reporting on is depth deep; once BeginxContext
+	// returns, it (or the new Node it creates) will be at depth+1, which is
+	// the depth its own Commit/Rollback hooks will later fire at.
+	h.startSpan(ctx, txID, name, depth+1)
+}
+
+// AfterBegin ends the span started by BeforeBegin if opening the transaction
+// or savepoint failed; on success the span stays open until the matching
+// Commit or Rollback.
+func (h *Hooks) AfterBegin(ctx context.Context, txID string, depth int, savepointID string, elapsed time.Duration, err error) {
+	if err == nil || !ownsSpan(depth, savepointID) {
+		return
+	}
+	h.endSpan(txID, depth+1, err)
+}
+
+// BeforeCommit is a no-op: the span is ended by AfterCommit.
+func (h *Hooks) BeforeCommit(ctx context.Context, txID string, depth int, savepointID string) {}
+
+// AfterCommit ends the span opened for this transaction or savepoint. A
+// nested Beginx that merely reused its parent's transaction owns no span of
+// its own, and must not end the parent's.
+func (h *Hooks) AfterCommit(ctx context.Context, txID string, depth int, savepointID string, elapsed time.Duration, err error) {
+	h.endSpan(txID, depth, err)
+}
+
+// BeforeRollback is a no-op: the span is ended by AfterRollback.
+func (h *Hooks) BeforeRollback(ctx context.Context, txID string, depth int, savepointID string) {}
+
+// AfterRollback ends the span opened for this transaction or savepoint. A
+// nested Beginx that merely reused its parent's transaction owns no span of
+// its own, and must not end the parent's.
+func (h *Hooks) AfterRollback(ctx context.Context, txID string, depth int, savepointID string, elapsed time.Duration, err error) {
+	h.endSpan(txID, depth, err)
+}
+
+// BeforeSavepoint adds an event noting that a SAVEPOINT statement is about to
+// run to the span carried by ctx, if any. Hooks does not put the spans it
+// starts into ctx, so this is the caller's span, not one started by Hooks.
+func (h *Hooks) BeforeSavepoint(ctx context.Context, txID string, depth int, savepointID string) {
+	trace.SpanFromContext(ctx).AddEvent("savepoint", trace.WithAttributes(
+		attribute.String("db.sqalx.savepoint_id", savepointID),
+	))
+}
+
+// AfterSavepoint is a no-op: failures are reported through AfterBegin.
+func (h *Hooks) AfterSavepoint(ctx context.Context, txID string, depth int, savepointID string, elapsed time.Duration, err error) {
+}
+
+// BeforeReleaseSavepoint adds an event to the savepoint's span noting that it
+// is about to be released.
+func (h *Hooks) BeforeReleaseSavepoint(ctx context.Context, txID string, depth int, savepointID string) {
+	if span, ok := h.span(txID); ok {
+		span.AddEvent("release savepoint")
+	}
+}
+
+// BeforeRollbackToSavepoint adds an event to the savepoint's span noting
+// that it is about to be rolled back to.
+func (h *Hooks) BeforeRollbackToSavepoint(ctx context.Context, txID string, depth int, savepointID string) {
+	if span, ok := h.span(txID); ok {
+		span.AddEvent("rollback to savepoint")
+	}
+}
+
+var _ sqalx.Hooks = (*Hooks)(nil)
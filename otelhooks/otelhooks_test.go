@@ -0,0 +1,132 @@
+package otelhooks_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/gbl08ma/sqalx/otelhooks"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newTestHooks() (*otelhooks.Hooks, *tracetest.InMemoryExporter) {
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	return otelhooks.New(provider.Tracer("sqalx_test")), exporter
+}
+
+func TestHooksTopLevelTransactionEndsSpanOnCommit(t *testing.T) {
+	hooks, exporter := newTestHooks()
+	ctx := context.Background()
+
+	hooks.BeforeBegin(ctx, "tx1", 0, "")
+	hooks.AfterBegin(ctx, "tx1", 0, "", 0, nil)
+	hooks.BeforeCommit(ctx, "tx1", 1, "")
+	hooks.AfterCommit(ctx, "tx1", 1, "", 0, nil)
+
+	spans := exporter.GetSpans()
+	require.Len(t, spans, 1)
+	require.Equal(t, "db.sqalx.transaction", spans[0].Name)
+	require.Empty(t, spans[0].Status.Description)
+}
+
+func TestHooksRecordsErrorOnRollback(t *testing.T) {
+	hooks, exporter := newTestHooks()
+	ctx := context.Background()
+
+	hooks.BeforeBegin(ctx, "tx1", 0, "")
+	hooks.AfterBegin(ctx, "tx1", 0, "", 0, nil)
+
+	failure := errors.New("boom")
+	hooks.BeforeRollback(ctx, "tx1", 1, "")
+	hooks.AfterRollback(ctx, "tx1", 1, "", 0, failure)
+
+	spans := exporter.GetSpans()
+	require.Len(t, spans, 1)
+	require.Equal(t, "db.sqalx.transaction", spans[0].Name)
+	require.NotEmpty(t, spans[0].Status.Description)
+}
+
+func TestHooksNestedReuseGetsNoSpan(t *testing.T) {
+	hooks, exporter := newTestHooks()
+	ctx := context.Background()
+
+	// open the top-level transaction's span first.
+	hooks.BeforeBegin(ctx, "tx1", 0, "")
+	hooks.AfterBegin(ctx, "tx1", 0, "", 0, nil)
+
+	// a nested Beginx that reuses its parent's transaction (no savepoint)
+	// fires Begin hooks with the parent's txID at the parent's depth (1), and
+	// its own Commit hooks at its own, one-deeper depth (2): it must not
+	// start a span of its own, and its Commit must not end the parent's span
+	// either.
+	hooks.BeforeBegin(ctx, "tx1", 1, "")
+	hooks.AfterBegin(ctx, "tx1", 1, "", 0, nil)
+	hooks.BeforeCommit(ctx, "tx1", 2, "")
+	hooks.AfterCommit(ctx, "tx1", 2, "", 0, nil)
+
+	require.Empty(t, exporter.GetSpans(), "the reuse commit must not have ended the parent's span")
+
+	// only the outer commit ends the span.
+	hooks.BeforeCommit(ctx, "tx1", 1, "")
+	hooks.AfterCommit(ctx, "tx1", 1, "", 0, nil)
+
+	spans := exporter.GetSpans()
+	require.Len(t, spans, 1)
+	require.Equal(t, "db.sqalx.transaction", spans[0].Name)
+	require.Empty(t, spans[0].Status.Description)
+}
+
+func TestHooksNestedReuseRollbackErrorDoesNotCorruptParentSpan(t *testing.T) {
+	hooks, exporter := newTestHooks()
+	ctx := context.Background()
+
+	hooks.BeforeBegin(ctx, "tx1", 0, "")
+	hooks.AfterBegin(ctx, "tx1", 0, "", 0, nil)
+
+	// a reuse node rolling back with an error (e.g. a validation failure
+	// inside a helper that reused the caller's transaction) must not stamp
+	// that error onto the still-open parent span. Its own Rollback hooks
+	// fire at depth 2, one deeper than the parent transaction's depth 1.
+	innerErr := errors.New("inner validation failed")
+	hooks.BeforeRollback(ctx, "tx1", 2, "")
+	hooks.AfterRollback(ctx, "tx1", 2, "", 0, innerErr)
+
+	require.Empty(t, exporter.GetSpans())
+
+	hooks.BeforeCommit(ctx, "tx1", 1, "")
+	hooks.AfterCommit(ctx, "tx1", 1, "", 0, nil)
+
+	spans := exporter.GetSpans()
+	require.Len(t, spans, 1)
+	require.Equal(t, "db.sqalx.transaction", spans[0].Name)
+	require.Empty(t, spans[0].Status.Description, "the parent transaction committed successfully and must not carry the reuse node's error")
+}
+
+func TestHooksSavepointIsItsOwnSpan(t *testing.T) {
+	hooks, exporter := newTestHooks()
+	ctx := context.Background()
+
+	hooks.BeforeBegin(ctx, "tx1", 0, "")
+	hooks.AfterBegin(ctx, "tx1", 0, "", 0, nil)
+
+	hooks.BeforeBegin(ctx, "sp1", 1, "sp1")
+	hooks.BeforeSavepoint(ctx, "sp1", 1, "sp1")
+	hooks.AfterBegin(ctx, "sp1", 1, "sp1", 0, nil)
+
+	hooks.BeforeCommit(ctx, "sp1", 2, "sp1")
+	hooks.BeforeReleaseSavepoint(ctx, "sp1", 2, "sp1")
+	hooks.AfterCommit(ctx, "sp1", 2, "sp1", 0, nil)
+
+	hooks.BeforeCommit(ctx, "tx1", 1, "")
+	hooks.AfterCommit(ctx, "tx1", 1, "", 0, nil)
+
+	spans := exporter.GetSpans()
+	require.Len(t, spans, 2)
+
+	names := []string{spans[0].Name, spans[1].Name}
+	require.ElementsMatch(t, []string{"db.sqalx.transaction", "db.sqalx.savepoint"}, names)
+}
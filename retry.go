@@ -0,0 +1,179 @@
+package sqalx
+
+import (
+	"context"
+	"math/rand"
+	"strings"
+	"time"
+
+	mysql "github.com/go-sql-driver/mysql"
+	pq "github.com/lib/pq"
+)
+
+// RetryPolicy controls how RunInTransaction retries a transaction that
+// failed because of a transient serialization or deadlock error.
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of times fn is invoked. Values below
+	// 1 are treated as 1 (no retry).
+	MaxAttempts int
+	// Backoff returns how long to wait before the attempt-th retry (0 is the
+	// delay before the second overall attempt). If nil, DefaultRetryPolicy's
+	// backoff is used.
+	Backoff func(attempt int) time.Duration
+	// Jitter perturbs a computed backoff duration to avoid thundering-herd
+	// retries. If nil, no jitter is applied.
+	Jitter func(d time.Duration) time.Duration
+	// Retryable reports whether err, returned while using the given driver,
+	// is a transient error worth retrying. If nil, nothing is retried.
+	Retryable func(driverName string, err error) bool
+}
+
+// DefaultRetryPolicy makes up to 5 attempts with capped exponential backoff
+// and jitter, retrying PostgreSQL/pgx serialization and deadlock failures
+// (SQLSTATE 40001/40P01), MySQL deadlocks (error 1213) and SQLite
+// SQLITE_BUSY errors.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 5,
+	Backoff:     defaultRetryBackoff,
+	Jitter:      defaultRetryJitter,
+	Retryable:   defaultRetryable,
+}
+
+func defaultRetryBackoff(attempt int) time.Duration {
+	d := 10 * time.Millisecond * time.Duration(uint(1)<<uint(attempt))
+	if d > time.Second {
+		d = time.Second
+	}
+	return d
+}
+
+func defaultRetryJitter(d time.Duration) time.Duration {
+	if d <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(d)))
+}
+
+// pgxSQLStateError matches the error interface implemented by pgx's error
+// types, without requiring a dependency on pgx.
+type pgxSQLStateError interface {
+	SQLState() string
+}
+
+func defaultRetryable(driverName string, err error) bool {
+	if err == nil {
+		return false
+	}
+
+	switch driverName {
+	case "postgres":
+		if pqErr, ok := err.(*pq.Error); ok {
+			return pqErr.Code == "40001" || pqErr.Code == "40P01"
+		}
+	case "pgx":
+		if stateErr, ok := err.(pgxSQLStateError); ok {
+			state := stateErr.SQLState()
+			return state == "40001" || state == "40P01"
+		}
+	case "mysql":
+		if myErr, ok := err.(*mysql.MySQLError); ok {
+			return myErr.Number == 1213
+		}
+	case "sqlite3":
+		// mattn/go-sqlite3 reports SQLITE_BUSY through this message; we
+		// match on it instead of importing the (cgo) driver package.
+		return strings.Contains(err.Error(), "database is locked")
+	}
+
+	return false
+}
+
+// RunInTransaction runs fn within a nested transaction opened on n: it
+// begins the transaction, calls fn, and commits on success or rolls back on
+// error. If fn's error is classified as retryable by policy, the whole
+// attempt (begin, fn, commit/rollback) is retried according to policy, up to
+// policy.MaxAttempts times.
+//
+// Retrying is only safe when the nested transaction has its own rollback
+// boundary (a brand new top-level transaction, or a SAVEPOINT). If n is
+// already inside a transaction that doesn't use savepoints, Beginx reuses
+// the parent transaction, rolling it back would discard the caller's work
+// too, so RunInTransaction does not retry in that case: fn's error is
+// returned immediately.
+func RunInTransaction(ctx context.Context, n Node, policy RetryPolicy, fn func(Node) error) error {
+	maxAttempts := policy.MaxAttempts
+	if maxAttempts < 1 {
+		maxAttempts = 1
+	}
+
+	var lastErr error
+
+	for attempt := 0; attempt < maxAttempts; attempt++ {
+		tx, err := n.BeginxContext(ctx, nil)
+		if err != nil {
+			return err
+		}
+
+		unsafeToRetry := isReusingParentTx(tx)
+
+		if err = fn(tx); err != nil {
+			tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+
+		if err == nil {
+			return nil
+		}
+
+		lastErr = err
+
+		if unsafeToRetry || policy.Retryable == nil || !policy.Retryable(n.DriverName(), err) {
+			return err
+		}
+
+		if attempt == maxAttempts-1 {
+			break
+		}
+
+		if waitErr := sleepForRetry(ctx, policy, attempt); waitErr != nil {
+			return waitErr
+		}
+	}
+
+	return lastErr
+}
+
+func sleepForRetry(ctx context.Context, policy RetryPolicy, attempt int) error {
+	backoff := policy.Backoff
+	if backoff == nil {
+		backoff = defaultRetryBackoff
+	}
+
+	delay := backoff(attempt)
+	if policy.Jitter != nil {
+		delay = policy.Jitter(delay)
+	}
+
+	if delay <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// isReusingParentTx reports whether n is a nested Node that reuses its
+// parent's transaction rather than owning a transaction or savepoint of its
+// own.
+func isReusingParentTx(n Node) bool {
+	nd, ok := n.(*node)
+	return ok && nd.nested && !nd.savePointEnabled
+}
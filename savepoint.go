@@ -0,0 +1,64 @@
+package sqalx
+
+import (
+	"sync"
+)
+
+// A SavepointDialect knows how to build the statements used to create,
+// release and roll back to a SAVEPOINT for a particular database driver.
+// Release may return an empty string for drivers that release savepoints
+// implicitly (e.g. SQL Server), in which case no statement is executed.
+type SavepointDialect struct {
+	Savepoint  func(id string) string
+	Release    func(id string) string
+	RollbackTo func(id string) string
+}
+
+var (
+	savepointDialectsMu sync.RWMutex
+	savepointDialects   = map[string]SavepointDialect{
+		"postgres": standardSavepointDialect,
+		"pgx":      standardSavepointDialect,
+		"sqlite3":  standardSavepointDialect,
+		"mysql": {
+			Savepoint:  func(id string) string { return "SAVEPOINT `" + id + "`" },
+			Release:    func(id string) string { return "RELEASE SAVEPOINT `" + id + "`" },
+			RollbackTo: func(id string) string { return "ROLLBACK TO SAVEPOINT `" + id + "`" },
+		},
+		"sqlserver": {
+			Savepoint:  func(id string) string { return "SAVE TRANSACTION " + id },
+			Release:    func(id string) string { return "" },
+			RollbackTo: func(id string) string { return "ROLLBACK TRANSACTION " + id },
+		},
+	}
+
+	standardSavepointDialect = SavepointDialect{
+		Savepoint:  func(id string) string { return "SAVEPOINT " + id },
+		Release:    func(id string) string { return "RELEASE SAVEPOINT " + id },
+		RollbackTo: func(id string) string { return "ROLLBACK TO SAVEPOINT " + id },
+	}
+)
+
+// RegisterSavepointDialect registers (or overrides) the SavepointDialect used
+// for driverName, making SavePoint(true) available to that driver. It is
+// typically called from an init function, before any Node using that driver
+// is created.
+func RegisterSavepointDialect(driverName string, dialect SavepointDialect) {
+	savepointDialectsMu.Lock()
+	defer savepointDialectsMu.Unlock()
+	savepointDialects[driverName] = dialect
+}
+
+func savepointDialectFor(driverName string) (SavepointDialect, bool) {
+	savepointDialectsMu.RLock()
+	defer savepointDialectsMu.RUnlock()
+	dialect, ok := savepointDialects[driverName]
+	return dialect, ok
+}
+
+// nextSavepointID returns a new savepoint identifier. Identifiers start with
+// a letter and contain no dashes, so they are valid unquoted identifiers on
+// every dialect in savepointDialects.
+func nextSavepointID() string {
+	return nextID("sp")
+}
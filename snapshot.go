@@ -0,0 +1,111 @@
+package sqalx
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+)
+
+// ErrReadOnlySnapshot is returned by Exec, ExecContext, NamedExec and
+// NamedExecContext when called on a Node returned by Snapshot; MustExec and
+// MustExecContext panic with it instead.
+var ErrReadOnlySnapshot = errors.New("sqalx: write attempted on a read-only snapshot")
+
+// Snapshot opens a read-only transaction intended for consistent
+// multi-statement reads. On PostgreSQL and MySQL it uses
+// sql.TxOptions{ReadOnly: true, Isolation: sql.LevelRepeatableRead}; SQLite
+// has no read-only transaction mode, so on "sqlite3" it instead begins a
+// plain (deferred) transaction and issues "PRAGMA query_only = ON" on it,
+// reverting it with "PRAGMA query_only = OFF" before the transaction ends:
+// the pragma is scoped to the underlying connection, not the transaction, so
+// leaving it set would make the connection read-only for whoever reuses it
+// from the pool next.
+//
+// Exec, ExecContext, MustExec, MustExecContext, NamedExec and
+// NamedExecContext on the returned Node fail with ErrReadOnlySnapshot
+// instead of being sent to the database, so misuse is caught even on
+// drivers that don't enforce read-only transactions. Commit and Rollback on
+// a snapshot Node end it as they would any other Node (after the
+// "PRAGMA query_only = OFF" reset on "sqlite3").
+//
+// Called on a Node that is already in a transaction, Snapshot nests in it as
+// BeginxContext does (reusing it, or opening a savepoint): the TxOptions
+// above are then only checked against the outer transaction's options, not
+// applied, and ending the snapshot Node does not end the outer transaction.
+func (n node) Snapshot(ctx context.Context) (Node, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	var (
+		child     Node
+		err       error
+		isSQLite3 = n.Driver.DriverName() == "sqlite3"
+	)
+
+	if isSQLite3 {
+		if child, err = n.BeginxContext(ctx, nil); err != nil {
+			return nil, err
+		}
+		if _, err = child.ExecContext(ctx, "PRAGMA query_only = ON"); err != nil {
+			child.Rollback()
+			return nil, err
+		}
+	} else {
+		child, err = n.BeginxContext(ctx, &sql.TxOptions{
+			ReadOnly:  true,
+			Isolation: sql.LevelRepeatableRead,
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	cn := child.(*node)
+	cn.snapshot = true
+	cn.sqliteSnapshot = isSQLite3
+
+	return cn, nil
+}
+
+func (n *node) Exec(query string, args ...interface{}) (sql.Result, error) {
+	if n.snapshot {
+		return nil, ErrReadOnlySnapshot
+	}
+	return n.Driver.Exec(query, args...)
+}
+
+func (n *node) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	if n.snapshot {
+		return nil, ErrReadOnlySnapshot
+	}
+	return n.Driver.ExecContext(ctx, query, args...)
+}
+
+func (n *node) MustExec(query string, args ...interface{}) sql.Result {
+	if n.snapshot {
+		panic(ErrReadOnlySnapshot)
+	}
+	return n.Driver.MustExec(query, args...)
+}
+
+func (n *node) MustExecContext(ctx context.Context, query string, args ...interface{}) sql.Result {
+	if n.snapshot {
+		panic(ErrReadOnlySnapshot)
+	}
+	return n.Driver.MustExecContext(ctx, query, args...)
+}
+
+func (n *node) NamedExec(query string, arg interface{}) (sql.Result, error) {
+	if n.snapshot {
+		return nil, ErrReadOnlySnapshot
+	}
+	return n.Driver.NamedExec(query, arg)
+}
+
+func (n *node) NamedExecContext(ctx context.Context, query string, arg interface{}) (sql.Result, error) {
+	if n.snapshot {
+		return nil, ErrReadOnlySnapshot
+	}
+	return n.Driver.NamedExecContext(ctx, query, arg)
+}
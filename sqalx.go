@@ -1,13 +1,13 @@
 package sqalx
 
 import (
+	"context"
 	"database/sql"
 	"errors"
-	"strings"
 	"sync"
+	"time"
 
 	"github.com/jmoiron/sqlx"
-	uuid "github.com/satori/go.uuid"
 )
 
 var (
@@ -18,6 +18,13 @@ var (
 	// ErrIncompatibleOption is returned when using an option incompatible
 	// with the selected driver.
 	ErrIncompatibleOption = errors.New("incompatible option")
+
+	// ErrConflictingTxOptions is returned by BeginxWithOptions (or
+	// BeginxContext with non-nil opts) when a nested call requests
+	// sql.TxOptions that are incompatible with its outer transaction, e.g. a
+	// stricter isolation level or read-write access inside a read-only
+	// outer transaction.
+	ErrConflictingTxOptions = errors.New("conflicting transaction options")
 )
 
 // A Node is a database driver that can manage nested transactions.
@@ -28,6 +35,21 @@ type Node interface {
 	Close() error
 	// Begin a new transaction.
 	Beginx() (Node, error)
+	// BeginxContext begins a new transaction, using ctx for the BEGIN/SAVEPOINT
+	// statement and for every statement run on the returned Node until it is
+	// committed or rolled back. opts is only applied when actually opening a
+	// new top-level transaction; when reusing or nesting an existing one, it
+	// is validated against the outer transaction's options instead, and
+	// ErrConflictingTxOptions is returned if they are incompatible.
+	BeginxContext(ctx context.Context, opts *sql.TxOptions) (Node, error)
+	// BeginxWithOptions begins a new transaction using opts, as BeginxContext
+	// does, but using the Node's current context.
+	BeginxWithOptions(opts *sql.TxOptions) (Node, error)
+	// Snapshot opens a read-only transaction intended for consistent
+	// multi-statement reads. Exec, ExecContext, MustExec, MustExecContext,
+	// NamedExec and NamedExecContext on the returned Node fail with
+	// ErrReadOnlySnapshot.
+	Snapshot(ctx context.Context) (Node, error)
 	// Rollback the associated transaction.
 	Rollback() error
 	// Commit the assiociated transaction.
@@ -64,18 +86,28 @@ type Node interface {
 // and therefore is limited to the methods they have in common.
 type Driver interface {
 	sqlx.Execer
+	sqlx.ExecerContext
 	sqlx.Queryer
+	sqlx.QueryerContext
 	sqlx.Preparer
+	sqlx.PreparerContext
 	BindNamed(query string, arg interface{}) (string, []interface{}, error)
 	DriverName() string
 	Get(dest interface{}, query string, args ...interface{}) error
+	GetContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error
 	MustExec(query string, args ...interface{}) sql.Result
+	MustExecContext(ctx context.Context, query string, args ...interface{}) sql.Result
 	NamedExec(query string, arg interface{}) (sql.Result, error)
+	NamedExecContext(ctx context.Context, query string, arg interface{}) (sql.Result, error)
 	NamedQuery(query string, arg interface{}) (*sqlx.Rows, error)
 	PrepareNamed(query string) (*sqlx.NamedStmt, error)
+	PrepareNamedContext(ctx context.Context, query string) (*sqlx.NamedStmt, error)
 	Preparex(query string) (*sqlx.Stmt, error)
+	PreparexContext(ctx context.Context, query string) (*sqlx.Stmt, error)
+	QueryxContext(ctx context.Context, query string, args ...interface{}) (*sqlx.Rows, error)
 	Rebind(query string) string
 	Select(dest interface{}, query string, args ...interface{}) error
+	SelectContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error
 }
 
 // New creates a new Node with the given DB.
@@ -84,6 +116,8 @@ func New(db *sqlx.DB, options ...Option) (Node, error) {
 		db:     db,
 		Driver: db,
 		smap:   new(sync.Map),
+		ctx:    context.Background(),
+		hooks:  NoopHooks{},
 	}
 
 	for _, opt := range options {
@@ -102,6 +136,8 @@ func NewFromTransaction(tx *sqlx.Tx, options ...Option) (Node, error) {
 		tx:     tx,
 		Driver: tx,
 		smap:   new(sync.Map),
+		ctx:    context.Background(),
+		hooks:  NoopHooks{},
 	}
 
 	for _, opt := range options {
@@ -137,9 +173,18 @@ type node struct {
 	db               *sqlx.DB
 	tx               *sqlx.Tx
 	smap             *sync.Map
+	ctx              context.Context
+	txOptions        *sql.TxOptions
+	defaultTxOptions *sql.TxOptions
 	savePointID      string
 	savePointEnabled bool
+	savePointDialect SavepointDialect
 	nested           bool
+	snapshot         bool
+	sqliteSnapshot   bool
+	hooks            Hooks
+	depth            int
+	id               string
 }
 
 func (n *node) Close() error {
@@ -147,46 +192,156 @@ func (n *node) Close() error {
 }
 
 func (n node) Beginx() (Node, error) {
+	return n.BeginxContext(n.ctx, nil)
+}
+
+// BeginxWithOptions begins a new transaction using opts, as BeginxContext
+// does, but using the Node's current context.
+func (n node) BeginxWithOptions(opts *sql.TxOptions) (Node, error) {
+	return n.BeginxContext(n.ctx, opts)
+}
+
+// BeginxContext begins a new transaction, using ctx for the BEGIN/SAVEPOINT
+// statement and for every statement run on the returned Node until it is
+// committed or rolled back. opts is only honored when actually opening a new
+// top-level transaction, in which case a nil opts falls back to the default
+// isolation set through the IsolationLevel option, if any. When reusing or
+// nesting an existing transaction, opts is instead validated against the
+// options the outer transaction was opened with, and BeginxContext returns
+// ErrConflictingTxOptions if they are incompatible.
+func (n node) BeginxContext(ctx context.Context, opts *sql.TxOptions) (Node, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	// the savepoint ID, if any, is known ahead of time so hooks can be told
+	// about it from BeforeBegin onwards. newID identifies the transaction or
+	// savepoint this Beginx call opens (or, when reusing the parent's
+	// transaction, the parent's own id) and is what ties a node's Begin hooks
+	// to its later Commit/Rollback hooks: unlike (ctx, depth), it stays
+	// unique even across concurrent sibling transactions sharing a context.
+	newSavePointID := ""
+	newID := n.id
+	switch {
+	case n.tx == nil:
+		newID = nextID("tx")
+	case n.savePointEnabled:
+		newSavePointID = nextSavepointID()
+		newID = newSavePointID
+	}
+
+	start := time.Now()
+	n.hooks.BeforeBegin(ctx, newID, n.depth, newSavePointID)
+
 	var err error
 
 	switch {
 	case n.tx == nil:
 		// new actual transaction
-		n.tx, err = n.db.Beginx()
+		if opts == nil {
+			opts = n.defaultTxOptions
+		}
+		n.tx, err = n.db.BeginTxx(ctx, opts)
+		n.txOptions = opts
 		// values are scoped to each transaction
 		n.smap = new(sync.Map)
 		n.Driver = n.tx
 	case n.savePointEnabled:
 		// already in a transaction: using savepoints
-		n.nested = true
-		// savepoints name must start with a char and cannot contain dashes (-)
-		n.savePointID = "sp_" + strings.Replace(uuid.Must(uuid.NewV1()).String(), "-", "_", -1)
-		_, err = n.tx.Exec("SAVEPOINT " + n.savePointID)
+		if err = conflictingTxOptions(n.txOptions, opts); err == nil {
+			n.nested = true
+			n.savePointID = newSavePointID
+			err = n.execSavepoint(ctx, newID)
+		}
 	default:
 		// already in a transaction: reusing current transaction
-		n.nested = true
+		if err = conflictingTxOptions(n.txOptions, opts); err == nil {
+			n.nested = true
+		}
 	}
 
+	n.hooks.AfterBegin(ctx, newID, n.depth, newSavePointID, time.Since(start), err)
+
 	if err != nil {
 		return nil, err
 	}
 
+	n.ctx = ctx
+	n.depth++
+	n.id = newID
+
 	return &n, nil
 }
 
+// execSavepoint runs the dialect's SAVEPOINT statement for n.savePointID,
+// wrapped in its own BeforeSavepoint/AfterSavepoint hook pair.
+func (n *node) execSavepoint(ctx context.Context, id string) error {
+	start := time.Now()
+	n.hooks.BeforeSavepoint(ctx, id, n.depth, n.savePointID)
+
+	var err error
+	if stmt := n.savePointDialect.Savepoint(n.savePointID); stmt != "" {
+		_, err = n.tx.ExecContext(ctx, stmt)
+	}
+
+	n.hooks.AfterSavepoint(ctx, id, n.depth, n.savePointID, time.Since(start), err)
+
+	return err
+}
+
+// conflictingTxOptions reports ErrConflictingTxOptions if nested requests
+// options that outer's already-open transaction cannot honor: a stricter
+// isolation level, or read-write access inside a read-only transaction. A
+// nil outer or nested means no constraint is being asked for, so there is
+// nothing to conflict with.
+func conflictingTxOptions(outer, nested *sql.TxOptions) error {
+	if outer == nil || nested == nil {
+		return nil
+	}
+
+	if outer.ReadOnly && !nested.ReadOnly {
+		return ErrConflictingTxOptions
+	}
+
+	if outer.Isolation != sql.LevelDefault && nested.Isolation != sql.LevelDefault &&
+		nested.Isolation > outer.Isolation {
+		return ErrConflictingTxOptions
+	}
+
+	return nil
+}
+
 func (n *node) Rollback() error {
 	if n.tx == nil {
 		return nil
 	}
 
+	start := time.Now()
+	n.hooks.BeforeRollback(n.ctx, n.id, n.depth, n.savePointID)
+
 	var err error
 
-	if n.savePointEnabled && n.savePointID != "" {
-		_, err = n.tx.Exec("ROLLBACK TO SAVEPOINT " + n.savePointID)
-	} else if !n.nested {
-		err = n.tx.Rollback()
+	// query_only is scoped to the connection, not to this node's savepoint or
+	// transaction, so it must come off as soon as this snapshot Node ends,
+	// whether or not that also ends the underlying transaction (it might be a
+	// savepoint, or a reuse of an outer transaction that keeps going).
+	if n.sqliteSnapshot {
+		_, err = n.tx.ExecContext(n.ctx, "PRAGMA query_only = OFF")
+	}
+
+	if err == nil {
+		if n.savePointEnabled && n.savePointID != "" {
+			n.hooks.BeforeRollbackToSavepoint(n.ctx, n.id, n.depth, n.savePointID)
+			if stmt := n.savePointDialect.RollbackTo(n.savePointID); stmt != "" {
+				_, err = n.tx.ExecContext(n.ctx, stmt)
+			}
+		} else if !n.nested {
+			err = n.tx.Rollback()
+		}
 	}
 
+	n.hooks.AfterRollback(n.ctx, n.id, n.depth, n.savePointID, time.Since(start), err)
+
 	if err != nil {
 		return err
 	}
@@ -202,14 +357,32 @@ func (n *node) Commit() error {
 		return ErrNotInTransaction
 	}
 
+	start := time.Now()
+	n.hooks.BeforeCommit(n.ctx, n.id, n.depth, n.savePointID)
+
 	var err error
 
-	if n.savePointID != "" {
-		_, err = n.tx.Exec("RELEASE SAVEPOINT " + n.savePointID)
-	} else if !n.nested {
-		err = n.tx.Commit()
+	// query_only is scoped to the connection, not to this node's savepoint or
+	// transaction, so it must come off as soon as this snapshot Node ends,
+	// whether or not that also ends the underlying transaction (it might be a
+	// savepoint, or a reuse of an outer transaction that keeps going).
+	if n.sqliteSnapshot {
+		_, err = n.tx.ExecContext(n.ctx, "PRAGMA query_only = OFF")
+	}
+
+	if err == nil {
+		if n.savePointID != "" {
+			n.hooks.BeforeReleaseSavepoint(n.ctx, n.id, n.depth, n.savePointID)
+			if stmt := n.savePointDialect.Release(n.savePointID); stmt != "" {
+				_, err = n.tx.ExecContext(n.ctx, stmt)
+			}
+		} else if !n.nested {
+			err = n.tx.Commit()
+		}
 	}
 
+	n.hooks.AfterCommit(n.ctx, n.id, n.depth, n.savePointID, time.Since(start), err)
+
 	if err != nil {
 		return err
 	}
@@ -266,13 +439,34 @@ func (n *node) Range(f func(key, value interface{}) bool) {
 // Option to configure sqalx
 type Option func(*node) error
 
-// SavePoint option enables PostgreSQL Savepoints for nested transactions.
+// SavePoint option enables SAVEPOINT-based nested transactions, using the
+// SavepointDialect registered for the Node's driver (see
+// RegisterSavepointDialect). It returns ErrIncompatibleOption if no dialect
+// is registered for the driver.
 func SavePoint(enabled bool) Option {
 	return func(n *node) error {
-		if enabled && n.Driver.DriverName() != "postgres" {
-			return ErrIncompatibleOption
+		if enabled {
+			dialect, ok := savepointDialectFor(n.Driver.DriverName())
+			if !ok {
+				return ErrIncompatibleOption
+			}
+			n.savePointDialect = dialect
 		}
 		n.savePointEnabled = enabled
 		return nil
 	}
 }
+
+// IsolationLevel option sets the default sql.TxOptions.Isolation used when
+// opening a new top-level transaction (Beginx, or BeginxContext/
+// BeginxWithOptions called with nil opts), so callers don't need to pass
+// options at every call site.
+func IsolationLevel(level sql.IsolationLevel) Option {
+	return func(n *node) error {
+		if n.defaultTxOptions == nil {
+			n.defaultTxOptions = &sql.TxOptions{}
+		}
+		n.defaultTxOptions.Isolation = level
+		return nil
+	}
+}
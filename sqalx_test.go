@@ -0,0 +1,721 @@
+package sqalx_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/gbl08ma/sqalx"
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/jmoiron/sqlx"
+	_ "github.com/lib/pq"
+	"github.com/stretchr/testify/require"
+)
+
+func prepareDB(t *testing.T, driverName string) (*sqlx.DB, sqlmock.Sqlmock, func()) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+
+	return sqlx.NewDb(db, driverName), mock, func() {
+		db.Close()
+	}
+}
+
+func TestSqalxConnectPostgreSQL(t *testing.T) {
+	dataSource := os.Getenv("POSTGRESQL_DATASOURCE")
+	if dataSource == "" {
+		t.Skip()
+		return
+	}
+
+	testSqalxConnect(t, "postgres", dataSource)
+	testSqalxConnect(t, "postgres", dataSource, sqalx.SavePoint(true))
+}
+
+func TestSqalxConnectMySQL(t *testing.T) {
+	dataSource := os.Getenv("MYSQL_DATASOURCE")
+	if dataSource == "" {
+		t.Skip()
+		return
+	}
+
+	testSqalxConnect(t, "mysql", dataSource)
+
+	node, err := sqalx.Connect("mysql", dataSource, sqalx.SavePoint(true))
+	require.NoError(t, err)
+	require.NotNil(t, node)
+	require.NoError(t, node.Close())
+}
+
+func testSqalxConnect(t *testing.T, driverName, dataSource string, options ...sqalx.Option) {
+	node, err := sqalx.Connect(driverName, dataSource, options...)
+	require.NoError(t, err)
+
+	err = node.Close()
+	require.NoError(t, err)
+}
+
+func TestSqalxTransactionViolations(t *testing.T) {
+	node, err := sqalx.New(nil)
+	require.NoError(t, err)
+
+	require.Panics(t, func() {
+		node.Exec("UPDATE products SET views = views + 1")
+	})
+
+	require.Panics(t, func() {
+		node.Beginx()
+	})
+
+	// calling Rollback after a transaction is closed does nothing
+	err = node.Rollback()
+	require.NoError(t, err)
+
+	err = node.Commit()
+	require.Equal(t, err, sqalx.ErrNotInTransaction)
+}
+
+func TestSqalxSimpleQuery(t *testing.T) {
+	db, mock, cleanup := prepareDB(t, "mock")
+	defer cleanup()
+
+	mock.ExpectExec("UPDATE products").WillReturnResult(sqlmock.NewResult(1, 1))
+
+	node, err := sqalx.New(db)
+	require.NoError(t, err)
+
+	_, err = node.Exec("UPDATE products SET views = views + 1")
+	require.NoError(t, err)
+}
+
+func TestSqalxSimpleQueryContext(t *testing.T) {
+	db, mock, cleanup := prepareDB(t, "mock")
+	defer cleanup()
+
+	mock.ExpectExec("UPDATE products").WillReturnResult(sqlmock.NewResult(1, 1))
+
+	node, err := sqalx.New(db)
+	require.NoError(t, err)
+
+	_, err = node.ExecContext(context.Background(), "UPDATE products SET views = views + 1")
+	require.NoError(t, err)
+}
+
+func TestSqalxTopLevelTransaction(t *testing.T) {
+	db, mock, cleanup := prepareDB(t, "mock")
+	defer cleanup()
+	var err error
+
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE products").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	node, err := sqalx.New(db)
+	require.NoError(t, err)
+
+	node, err = node.Beginx()
+	require.NoError(t, err)
+	require.NotNil(t, node)
+	defer func() {
+		err = node.Rollback()
+		require.NoError(t, err)
+	}()
+
+	_, err = node.Exec("UPDATE products SET views = views + 1")
+	require.NoError(t, err)
+
+	err = node.Commit()
+	require.NoError(t, err)
+}
+
+func TestSqalxTopLevelTransactionContext(t *testing.T) {
+	db, mock, cleanup := prepareDB(t, "mock")
+	defer cleanup()
+	var err error
+
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE products").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	node, err := sqalx.New(db)
+	require.NoError(t, err)
+
+	node, err = node.BeginxContext(context.Background(), nil)
+	require.NoError(t, err)
+	require.NotNil(t, node)
+	defer func() {
+		err = node.Rollback()
+		require.NoError(t, err)
+	}()
+
+	_, err = node.ExecContext(context.Background(), "UPDATE products SET views = views + 1")
+	require.NoError(t, err)
+
+	err = node.Commit()
+	require.NoError(t, err)
+}
+
+func TestSqalxSavePointIncompatibleDriver(t *testing.T) {
+	db, _, cleanup := prepareDB(t, "mock")
+	defer cleanup()
+
+	node, err := sqalx.New(db, sqalx.SavePoint(true))
+	require.Equal(t, sqalx.ErrIncompatibleOption, err)
+	require.Nil(t, node)
+}
+
+func TestSqalxRegisterSavepointDialect(t *testing.T) {
+	db, mock, cleanup := prepareDB(t, "mock")
+	defer cleanup()
+
+	sqalx.RegisterSavepointDialect("mock", sqalx.SavepointDialect{
+		Savepoint:  func(id string) string { return "SAVEPOINT " + id },
+		Release:    func(id string) string { return "RELEASE SAVEPOINT " + id },
+		RollbackTo: func(id string) string { return "ROLLBACK TO SAVEPOINT " + id },
+	})
+
+	mock.ExpectBegin()
+	mock.ExpectExec("SAVEPOINT").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("RELEASE SAVEPOINT").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	node, err := sqalx.New(db, sqalx.SavePoint(true))
+	require.NoError(t, err)
+
+	node, err = node.Beginx()
+	require.NoError(t, err)
+
+	n1, err := node.Beginx()
+	require.NoError(t, err)
+
+	err = n1.Commit()
+	require.NoError(t, err)
+
+	err = node.Commit()
+	require.NoError(t, err)
+}
+
+func TestSqalxBeginxWithOptionsTopLevel(t *testing.T) {
+	db, mock, cleanup := prepareDB(t, "mock")
+	defer cleanup()
+
+	mock.ExpectBegin()
+	mock.ExpectCommit()
+
+	node, err := sqalx.New(db)
+	require.NoError(t, err)
+
+	node, err = node.BeginxWithOptions(&sql.TxOptions{ReadOnly: true})
+	require.NoError(t, err)
+	require.NotNil(t, node)
+
+	err = node.Commit()
+	require.NoError(t, err)
+}
+
+func TestSqalxIsolationLevelOption(t *testing.T) {
+	db, mock, cleanup := prepareDB(t, "mock")
+	defer cleanup()
+
+	mock.ExpectBegin()
+	mock.ExpectCommit()
+
+	node, err := sqalx.New(db, sqalx.IsolationLevel(sql.LevelSerializable))
+	require.NoError(t, err)
+
+	node, err = node.Beginx()
+	require.NoError(t, err)
+	require.NotNil(t, node)
+
+	err = node.Commit()
+	require.NoError(t, err)
+}
+
+func TestSqalxConflictingNestedTxOptions(t *testing.T) {
+	db, mock, cleanup := prepareDB(t, "mock")
+	defer cleanup()
+
+	mock.ExpectBegin()
+
+	node, err := sqalx.New(db)
+	require.NoError(t, err)
+
+	parent, err := node.BeginxWithOptions(&sql.TxOptions{ReadOnly: true})
+	require.NoError(t, err)
+	defer parent.Rollback()
+
+	child, err := parent.BeginxWithOptions(&sql.TxOptions{ReadOnly: false})
+	require.Equal(t, sqalx.ErrConflictingTxOptions, err)
+	require.Nil(t, child)
+}
+
+func TestSqalxSnapshotRejectsWrites(t *testing.T) {
+	db, mock, cleanup := prepareDB(t, "mock")
+	defer cleanup()
+
+	mock.ExpectBegin()
+	mock.ExpectRollback()
+
+	node, err := sqalx.New(db)
+	require.NoError(t, err)
+
+	snap, err := node.Snapshot(context.Background())
+	require.NoError(t, err)
+	defer snap.Rollback()
+
+	_, err = snap.Exec("UPDATE products SET views = views + 1")
+	require.Equal(t, sqalx.ErrReadOnlySnapshot, err)
+
+	_, err = snap.ExecContext(context.Background(), "UPDATE products SET views = views + 1")
+	require.Equal(t, sqalx.ErrReadOnlySnapshot, err)
+
+	_, err = snap.NamedExec("UPDATE products SET views = views + :n", map[string]interface{}{"n": 1})
+	require.Equal(t, sqalx.ErrReadOnlySnapshot, err)
+
+	require.PanicsWithValue(t, sqalx.ErrReadOnlySnapshot, func() {
+		snap.MustExec("UPDATE products SET views = views + 1")
+	})
+}
+
+func TestSqalxSnapshotCommit(t *testing.T) {
+	db, mock, cleanup := prepareDB(t, "mock")
+	defer cleanup()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT").WillReturnRows(sqlmock.NewRows([]string{"views"}).AddRow(1))
+	mock.ExpectCommit()
+
+	node, err := sqalx.New(db)
+	require.NoError(t, err)
+
+	snap, err := node.Snapshot(context.Background())
+	require.NoError(t, err)
+
+	rows, err := snap.Query("SELECT views FROM products")
+	require.NoError(t, err)
+	rows.Close()
+
+	err = snap.Commit()
+	require.NoError(t, err)
+}
+
+func TestSqalxSnapshotCommitSQLite(t *testing.T) {
+	db, mock, cleanup := prepareDB(t, "sqlite3")
+	defer cleanup()
+
+	mock.ExpectBegin()
+	mock.ExpectExec("PRAGMA query_only = ON").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT").WillReturnRows(sqlmock.NewRows([]string{"views"}).AddRow(1))
+	mock.ExpectExec("PRAGMA query_only = OFF").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+
+	node, err := sqalx.New(db)
+	require.NoError(t, err)
+
+	snap, err := node.Snapshot(context.Background())
+	require.NoError(t, err)
+
+	rows, err := snap.Query("SELECT views FROM products")
+	require.NoError(t, err)
+	rows.Close()
+
+	// the connection must be left writable again once the snapshot ends, so
+	// the pool doesn't hand out a connection that's stuck read-only.
+	err = snap.Commit()
+	require.NoError(t, err)
+}
+
+func TestSqalxSnapshotRollbackSQLite(t *testing.T) {
+	db, mock, cleanup := prepareDB(t, "sqlite3")
+	defer cleanup()
+
+	mock.ExpectBegin()
+	mock.ExpectExec("PRAGMA query_only = ON").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("PRAGMA query_only = OFF").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+
+	node, err := sqalx.New(db)
+	require.NoError(t, err)
+
+	snap, err := node.Snapshot(context.Background())
+	require.NoError(t, err)
+
+	err = snap.Rollback()
+	require.NoError(t, err)
+}
+
+func TestSqalxSnapshotNestedInTransactionSQLite(t *testing.T) {
+	db, mock, cleanup := prepareDB(t, "sqlite3")
+	defer cleanup()
+
+	// Snapshot called on a Node already inside a transaction reuses that
+	// transaction (nested, no savepoint) instead of opening a new one, so its
+	// own Commit never issues a real COMMIT. The PRAGMA reset must still run,
+	// or the connection is left read-only for the still-ongoing outer
+	// transaction.
+	mock.ExpectBegin()
+	mock.ExpectExec("PRAGMA query_only = ON").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("PRAGMA query_only = OFF").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("UPDATE products").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	node, err := sqalx.New(db)
+	require.NoError(t, err)
+
+	outer, err := node.Beginx()
+	require.NoError(t, err)
+
+	snap, err := outer.Snapshot(context.Background())
+	require.NoError(t, err)
+
+	err = snap.Commit()
+	require.NoError(t, err)
+
+	_, err = outer.Exec("UPDATE products SET views = views + 1")
+	require.NoError(t, err)
+
+	err = outer.Commit()
+	require.NoError(t, err)
+}
+
+func TestSqalxNestedTransactions(t *testing.T) {
+	testSqalxNestedTransactions(t, false)
+}
+
+func TestSqalxNestedTransactionsWithSavePoint(t *testing.T) {
+	testSqalxNestedTransactions(t, true)
+}
+
+func testSqalxNestedTransactions(t *testing.T, testSavePoint bool) {
+	driverName := "mock"
+	if testSavePoint {
+		driverName = "postgres"
+	}
+
+	db, mock, cleanup := prepareDB(t, driverName)
+	defer cleanup()
+
+	require.Equal(t, driverName, db.DriverName())
+
+	var err error
+	const query = "UPDATE products SET views = views + 1"
+
+	mock.ExpectExec("UPDATE products").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE products").WillReturnResult(sqlmock.NewResult(1, 1))
+	if testSavePoint {
+		mock.ExpectExec("SAVEPOINT").WillReturnResult(sqlmock.NewResult(1, 1))
+	}
+	mock.ExpectExec("UPDATE products").WillReturnResult(sqlmock.NewResult(1, 1))
+	if testSavePoint {
+		mock.ExpectExec("ROLLBACK TO SAVEPOINT").WillReturnResult(sqlmock.NewResult(1, 1))
+	}
+	if testSavePoint {
+		mock.ExpectExec("SAVEPOINT").WillReturnResult(sqlmock.NewResult(1, 1))
+	}
+	mock.ExpectExec("UPDATE products").WillReturnResult(sqlmock.NewResult(1, 1))
+	if testSavePoint {
+		mock.ExpectExec("RELEASE SAVEPOINT").WillReturnResult(sqlmock.NewResult(1, 1))
+	}
+	mock.ExpectCommit()
+
+	node, err := sqalx.New(db, sqalx.SavePoint(testSavePoint))
+	require.NoError(t, err)
+
+	_, err = node.Exec(query)
+	require.NoError(t, err)
+
+	n1, err := node.Beginx()
+	require.NoError(t, err)
+	require.NotNil(t, n1)
+
+	_, err = n1.Exec(query)
+	require.NoError(t, err)
+
+	n1_1, err := n1.Beginx()
+	require.NoError(t, err)
+	require.NotNil(t, n1_1)
+
+	_, err = n1_1.Exec(query)
+	require.NoError(t, err)
+
+	err = n1_1.Rollback()
+	require.NoError(t, err)
+
+	err = n1_1.Commit()
+	require.Equal(t, sqalx.ErrNotInTransaction, err)
+
+	n1_1, err = n1.Beginx()
+	require.NoError(t, err)
+	require.NotNil(t, n1_1)
+
+	_, err = n1_1.Exec(query)
+	require.NoError(t, err)
+
+	err = n1_1.Commit()
+	require.NoError(t, err)
+
+	err = n1_1.Commit()
+	require.Equal(t, sqalx.ErrNotInTransaction, err)
+
+	err = n1_1.Rollback()
+	require.NoError(t, err)
+
+	err = n1.Commit()
+	require.NoError(t, err)
+}
+
+func TestRunInTransactionCommitsOnSuccess(t *testing.T) {
+	db, mock, cleanup := prepareDB(t, "mock")
+	defer cleanup()
+
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE products").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	node, err := sqalx.New(db)
+	require.NoError(t, err)
+
+	err = sqalx.RunInTransaction(context.Background(), node, sqalx.DefaultRetryPolicy, func(tx sqalx.Node) error {
+		_, err := tx.Exec("UPDATE products SET views = views + 1")
+		return err
+	})
+	require.NoError(t, err)
+}
+
+func TestRunInTransactionRollsBackOnError(t *testing.T) {
+	db, mock, cleanup := prepareDB(t, "mock")
+	defer cleanup()
+
+	mock.ExpectBegin()
+	mock.ExpectRollback()
+
+	node, err := sqalx.New(db)
+	require.NoError(t, err)
+
+	wantErr := errors.New("boom")
+	err = sqalx.RunInTransaction(context.Background(), node, sqalx.DefaultRetryPolicy, func(tx sqalx.Node) error {
+		return wantErr
+	})
+	require.Equal(t, wantErr, err)
+}
+
+func TestRunInTransactionRetriesRetryableError(t *testing.T) {
+	db, mock, cleanup := prepareDB(t, "mock")
+	defer cleanup()
+
+	mock.ExpectBegin()
+	mock.ExpectRollback()
+	mock.ExpectBegin()
+	mock.ExpectCommit()
+
+	node, err := sqalx.New(db)
+	require.NoError(t, err)
+
+	retryableErr := errors.New("retry me")
+	policy := sqalx.DefaultRetryPolicy
+	policy.Backoff = func(attempt int) time.Duration { return 0 }
+	policy.Retryable = func(driverName string, err error) bool { return err == retryableErr }
+
+	attempts := 0
+	err = sqalx.RunInTransaction(context.Background(), node, policy, func(tx sqalx.Node) error {
+		attempts++
+		if attempts == 1 {
+			return retryableErr
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, 2, attempts)
+}
+
+func TestRunInTransactionDoesNotRetryReusedParentTx(t *testing.T) {
+	db, mock, cleanup := prepareDB(t, "mock")
+	defer cleanup()
+
+	mock.ExpectBegin()
+
+	node, err := sqalx.New(db)
+	require.NoError(t, err)
+
+	parent, err := node.Beginx()
+	require.NoError(t, err)
+	defer parent.Rollback()
+
+	retryableErr := errors.New("retry me")
+	policy := sqalx.DefaultRetryPolicy
+	policy.Retryable = func(driverName string, err error) bool { return true }
+
+	attempts := 0
+	err = sqalx.RunInTransaction(context.Background(), parent, policy, func(tx sqalx.Node) error {
+		attempts++
+		return retryableErr
+	})
+	require.Equal(t, retryableErr, err)
+	require.Equal(t, 1, attempts)
+}
+
+func TestSqalxFromTransaction(t *testing.T) {
+	db, mock, cleanup := prepareDB(t, "mock")
+	defer cleanup()
+
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE products").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("UPDATE products").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectRollback()
+
+	tx, err := db.Beginx()
+	require.NoError(t, err)
+
+	node, err := sqalx.NewFromTransaction(tx)
+	require.NoError(t, err)
+
+	_, err = node.Exec("UPDATE products SET views = views + 1")
+	require.NoError(t, err)
+
+	ntx, err := node.Beginx()
+	_, err = ntx.Exec("UPDATE products SET views = views + 1")
+	require.NoError(t, err)
+
+	err = ntx.Rollback()
+	require.NoError(t, err)
+
+	err = node.Rollback()
+	require.NoError(t, err)
+}
+
+// recordingHooks is a test double recording every sqalx.Hooks callback it
+// receives, in order.
+type recordingHooks struct {
+	sqalx.NoopHooks
+	events []string
+}
+
+func (h *recordingHooks) BeforeBegin(ctx context.Context, txID string, depth int, savepointID string) {
+	h.events = append(h.events, fmt.Sprintf("BeforeBegin txID=%q depth=%d savepointID=%q", txID, depth, savepointID))
+}
+
+func (h *recordingHooks) AfterBegin(ctx context.Context, txID string, depth int, savepointID string, elapsed time.Duration, err error) {
+	h.events = append(h.events, fmt.Sprintf("AfterBegin txID=%q depth=%d savepointID=%q err=%v", txID, depth, savepointID, err))
+}
+
+func (h *recordingHooks) BeforeCommit(ctx context.Context, txID string, depth int, savepointID string) {
+	h.events = append(h.events, fmt.Sprintf("BeforeCommit txID=%q depth=%d savepointID=%q", txID, depth, savepointID))
+}
+
+func (h *recordingHooks) AfterCommit(ctx context.Context, txID string, depth int, savepointID string, elapsed time.Duration, err error) {
+	h.events = append(h.events, fmt.Sprintf("AfterCommit txID=%q depth=%d savepointID=%q err=%v", txID, depth, savepointID, err))
+}
+
+func (h *recordingHooks) BeforeSavepoint(ctx context.Context, txID string, depth int, savepointID string) {
+	h.events = append(h.events, fmt.Sprintf("BeforeSavepoint txID=%q depth=%d savepointID=%q", txID, depth, savepointID))
+}
+
+func (h *recordingHooks) BeforeReleaseSavepoint(ctx context.Context, txID string, depth int, savepointID string) {
+	h.events = append(h.events, fmt.Sprintf("BeforeReleaseSavepoint txID=%q depth=%d savepointID=%q", txID, depth, savepointID))
+}
+
+func TestSqalxHooksTopLevelTransaction(t *testing.T) {
+	db, mock, cleanup := prepareDB(t, "mock")
+	defer cleanup()
+
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE products").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	hooks := &recordingHooks{}
+
+	node, err := sqalx.New(db, sqalx.WithHooks(hooks))
+	require.NoError(t, err)
+
+	node, err = node.Beginx()
+	require.NoError(t, err)
+
+	_, err = node.Exec("UPDATE products SET views = views + 1")
+	require.NoError(t, err)
+
+	err = node.Commit()
+	require.NoError(t, err)
+
+	require.Len(t, hooks.events, 4)
+
+	const prefix = `BeforeBegin txID="`
+	require.True(t, strings.HasPrefix(hooks.events[0], prefix))
+	txID := strings.SplitN(strings.TrimPrefix(hooks.events[0], prefix), `"`, 2)[0]
+	require.NotEmpty(t, txID)
+
+	// the same txID must tie BeforeBegin/AfterBegin to the later
+	// BeforeCommit/AfterCommit, even though depth differs between them (the
+	// node returned by Beginx is one level deeper than the node it was
+	// called on): that's what lets a Hooks consumer key a span by txID
+	// alone, per transaction.
+	require.Equal(t, []string{
+		fmt.Sprintf(`BeforeBegin txID=%q depth=0 savepointID=""`, txID),
+		fmt.Sprintf(`AfterBegin txID=%q depth=0 savepointID="" err=<nil>`, txID),
+		fmt.Sprintf(`BeforeCommit txID=%q depth=1 savepointID=""`, txID),
+		fmt.Sprintf(`AfterCommit txID=%q depth=1 savepointID="" err=<nil>`, txID),
+	}, hooks.events)
+}
+
+func TestSqalxHooksSavePoint(t *testing.T) {
+	db, mock, cleanup := prepareDB(t, "postgres")
+	defer cleanup()
+
+	mock.ExpectBegin()
+	mock.ExpectExec("SAVEPOINT").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("UPDATE products").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("RELEASE SAVEPOINT").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	hooks := &recordingHooks{}
+
+	node, err := sqalx.New(db, sqalx.SavePoint(true), sqalx.WithHooks(hooks))
+	require.NoError(t, err)
+
+	node, err = node.Beginx()
+	require.NoError(t, err)
+
+	nested, err := node.Beginx()
+	require.NoError(t, err)
+
+	_, err = nested.Exec("UPDATE products SET views = views + 1")
+	require.NoError(t, err)
+
+	err = nested.Commit()
+	require.NoError(t, err)
+
+	err = node.Commit()
+	require.NoError(t, err)
+
+	require.Len(t, hooks.events, 10)
+
+	const topPrefix = `BeforeBegin txID="`
+	require.True(t, strings.HasPrefix(hooks.events[0], topPrefix))
+	topTxID := strings.SplitN(strings.TrimPrefix(hooks.events[0], topPrefix), `"`, 2)[0]
+	require.NotEmpty(t, topTxID)
+
+	require.Equal(t, fmt.Sprintf(`BeforeBegin txID=%q depth=0 savepointID=""`, topTxID), hooks.events[0])
+	require.Equal(t, fmt.Sprintf(`AfterBegin txID=%q depth=0 savepointID="" err=<nil>`, topTxID), hooks.events[1])
+
+	const nestedPrefix = `BeforeBegin txID="`
+	require.True(t, strings.HasPrefix(hooks.events[2], nestedPrefix))
+	savepointID := strings.SplitN(strings.TrimPrefix(hooks.events[2], nestedPrefix), `"`, 2)[0]
+	require.NotEmpty(t, savepointID)
+	require.NotEqual(t, topTxID, savepointID)
+
+	// for a savepoint, the txID sqalx assigns is the savepoint ID itself.
+	require.Equal(t, fmt.Sprintf(`BeforeBegin txID=%q depth=1 savepointID=%q`, savepointID, savepointID), hooks.events[2])
+	require.Equal(t, fmt.Sprintf(`BeforeSavepoint txID=%q depth=1 savepointID=%q`, savepointID, savepointID), hooks.events[3])
+	require.Equal(t, fmt.Sprintf(`AfterBegin txID=%q depth=1 savepointID=%q err=<nil>`, savepointID, savepointID), hooks.events[4])
+	require.Equal(t, fmt.Sprintf(`BeforeCommit txID=%q depth=2 savepointID=%q`, savepointID, savepointID), hooks.events[5])
+	require.Equal(t, fmt.Sprintf(`BeforeReleaseSavepoint txID=%q depth=2 savepointID=%q`, savepointID, savepointID), hooks.events[6])
+	require.Equal(t, fmt.Sprintf(`AfterCommit txID=%q depth=2 savepointID=%q err=<nil>`, savepointID, savepointID), hooks.events[7])
+	require.Equal(t, fmt.Sprintf(`BeforeCommit txID=%q depth=1 savepointID=""`, topTxID), hooks.events[8])
+	require.Equal(t, fmt.Sprintf(`AfterCommit txID=%q depth=1 savepointID="" err=<nil>`, topTxID), hooks.events[9])
+}